@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func stopContainer(name string) error {
+	return docker.ContainerStop(context.Background(), name, nil)
+}
+
+func restartContainer(name string) error {
+	return docker.ContainerRestart(context.Background(), name, nil)
+}
+
+func removeContainer(name string, force bool, removeVolumes bool) error {
+	return docker.ContainerRemove(context.Background(), name, types.ContainerRemoveOptions{
+		Force:         force,
+		RemoveVolumes: removeVolumes,
+	})
+}
+
+func inspectContainer(name string) (types.ContainerJSON, error) {
+	return docker.ContainerInspect(context.Background(), name)
+}
+
+// streamContainerLogs writes the container's log stream to c's response as
+// chunked transfer, demultiplexing stdout/stderr when the container was
+// created without a TTY.
+func streamContainerLogs(c *fiber.Ctx, name string, options types.ContainerLogsOptions) error {
+	info, err := inspectContainer(name)
+	if err != nil {
+		return err
+	}
+
+	reader, err := docker.ContainerLogs(context.Background(), name, options)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer w.Flush()
+		if info.Config.Tty {
+			io.Copy(w, reader)
+			w.Flush()
+			return
+		}
+		stdcopy.StdCopy(w, w, reader)
+	})
+	return nil
+}
+
+// containerStatsSample is the derived, per-tick stats payload emitted on the
+// stats stream - it trades the raw cgroup counters for the numbers a caller
+// actually wants (percentages and totals), the same way the Docker/Podman
+// compat stats handlers do.
+type containerStatsSample struct {
+	Read        string  `json:"read"`
+	CPUPercent  float64 `json:"cpuPercent"`
+	MemoryUsage uint64  `json:"memoryUsage"`
+	MemoryLimit uint64  `json:"memoryLimit"`
+	NetworkRx   uint64  `json:"networkRx"`
+	NetworkTx   uint64  `json:"networkTx"`
+	BlockRead   uint64  `json:"blockRead"`
+	BlockWrite  uint64  `json:"blockWrite"`
+}
+
+func calculateCPUPercent(stats *types.StatsJSON) float64 {
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
+	if systemDelta <= 0 || cpuDelta <= 0 {
+		return 0
+	}
+	onlineCPUs := float64(stats.CPUStats.OnlineCPUs)
+	if onlineCPUs == 0 {
+		onlineCPUs = float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if onlineCPUs == 0 {
+		onlineCPUs = 1
+	}
+	return (cpuDelta / systemDelta) * onlineCPUs * 100
+}
+
+func sumBlkioIO(stats *types.StatsJSON) (read uint64, write uint64) {
+	for _, entry := range stats.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			read += entry.Value
+		case "write":
+			write += entry.Value
+		}
+	}
+	return read, write
+}
+
+func sumNetworkIO(stats *types.StatsJSON) (rx uint64, tx uint64) {
+	for _, net := range stats.Networks {
+		rx += net.RxBytes
+		tx += net.TxBytes
+	}
+	return rx, tx
+}
+
+// streamContainerStats writes newline-delimited JSON stat samples computed
+// from docker.ContainerStats to c's response, one per tick, until the client
+// disconnects or the stats stream ends.
+func streamContainerStats(c *fiber.Ctx, name string, stream bool) error {
+	statsResp, err := docker.ContainerStats(context.Background(), name, stream)
+	if err != nil {
+		return err
+	}
+	defer statsResp.Body.Close()
+
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer w.Flush()
+		decoder := json.NewDecoder(statsResp.Body)
+		for {
+			var stats types.StatsJSON
+			if err := decoder.Decode(&stats); err != nil {
+				return
+			}
+			blockRead, blockWrite := sumBlkioIO(&stats)
+			networkRx, networkTx := sumNetworkIO(&stats)
+			sample := containerStatsSample{
+				Read:        stats.Read.Format("2006-01-02T15:04:05.000000000Z07:00"),
+				CPUPercent:  calculateCPUPercent(&stats),
+				MemoryUsage: stats.MemoryStats.Usage,
+				MemoryLimit: stats.MemoryStats.Limit,
+				NetworkRx:   networkRx,
+				NetworkTx:   networkTx,
+				BlockRead:   blockRead,
+				BlockWrite:  blockWrite,
+			}
+			encoded, err := json.Marshal(sample)
+			if err != nil {
+				return
+			}
+			if _, err := w.Write(append(encoded, '\n')); err != nil {
+				return
+			}
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	})
+	return nil
+}
+
+func registerContainerRoutes(app *fiber.App) {
+	app.Post("/containers/:name/stop", func(c *fiber.Ctx) error {
+		if err := stopContainer(c.Params("name")); err != nil {
+			return c.Status(500).JSON(&fiber.Map{"error": 1, "message": err.Error()})
+		}
+		return c.JSON(&fiber.Map{"error": 0, "message": "Container Stopped"})
+	})
+
+	app.Post("/containers/:name/restart", func(c *fiber.Ctx) error {
+		if err := restartContainer(c.Params("name")); err != nil {
+			return c.Status(500).JSON(&fiber.Map{"error": 1, "message": err.Error()})
+		}
+		return c.JSON(&fiber.Map{"error": 0, "message": "Container Restarted"})
+	})
+
+	app.Delete("/containers/:name", func(c *fiber.Ctx) error {
+		force := c.Query("force") == "1"
+		volumes := c.Query("volumes") == "1"
+		if err := removeContainer(c.Params("name"), force, volumes); err != nil {
+			return c.Status(500).JSON(&fiber.Map{"error": 1, "message": err.Error()})
+		}
+		return c.JSON(&fiber.Map{"error": 0, "message": "Container Removed"})
+	})
+
+	app.Get("/containers/:name/inspect", func(c *fiber.Ctx) error {
+		info, err := inspectContainer(c.Params("name"))
+		if err != nil {
+			return c.Status(500).JSON(&fiber.Map{"error": 1, "message": err.Error()})
+		}
+		return c.JSON(info)
+	})
+
+	app.Get("/containers/:name/logs", func(c *fiber.Ctx) error {
+		tail := c.Query("tail")
+		if tail == "" {
+			tail = "all"
+		} else if _, err := strconv.Atoi(tail); err != nil {
+			return c.Status(400).JSON(&fiber.Map{"error": 1, "message": "invalid tail: " + tail})
+		}
+
+		options := types.ContainerLogsOptions{
+			ShowStdout: c.Query("stdout") != "0",
+			ShowStderr: c.Query("stderr") != "0",
+			Follow:     c.Query("follow") == "1",
+			Tail:       tail,
+		}
+		if err := streamContainerLogs(c, c.Params("name"), options); err != nil {
+			return c.Status(500).JSON(&fiber.Map{"error": 1, "message": err.Error()})
+		}
+		return nil
+	})
+
+	app.Get("/containers/:name/stats", func(c *fiber.Ctx) error {
+		if err := streamContainerStats(c, c.Params("name"), c.Query("stream") == "1"); err != nil {
+			return c.Status(500).JSON(&fiber.Map{"error": 1, "message": err.Error()})
+		}
+		return nil
+	})
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+)
+
+// HealthcheckRequest mirrors container.HealthConfig, with durations given as
+// strings parseable by time.ParseDuration (e.g. "5s", "1m30s").
+type HealthcheckRequest struct {
+	Test        []string `json:"test"`
+	Interval    string   `json:"interval"`
+	Timeout     string   `json:"timeout"`
+	Retries     int      `json:"retries"`
+	StartPeriod string   `json:"startPeriod"`
+}
+
+const (
+	defaultHealthPollInterval = 2 * time.Second
+	defaultHealthRetries      = 5
+)
+
+func parseDurationOrDefault(val string, fallback time.Duration) (time.Duration, error) {
+	if val == "" {
+		return fallback, nil
+	}
+	return time.ParseDuration(val)
+}
+
+func buildHealthConfig(request *HealthcheckRequest) (*container.HealthConfig, error) {
+	if request == nil {
+		return nil, nil
+	}
+
+	interval, err := parseDurationOrDefault(request.Interval, 0)
+	if err != nil {
+		return nil, newValidationError("invalid healthcheck interval %q: %s", request.Interval, err.Error())
+	}
+	timeout, err := parseDurationOrDefault(request.Timeout, 0)
+	if err != nil {
+		return nil, newValidationError("invalid healthcheck timeout %q: %s", request.Timeout, err.Error())
+	}
+	startPeriod, err := parseDurationOrDefault(request.StartPeriod, 0)
+	if err != nil {
+		return nil, newValidationError("invalid healthcheck startPeriod %q: %s", request.StartPeriod, err.Error())
+	}
+
+	return &container.HealthConfig{
+		Test:        request.Test,
+		Interval:    interval,
+		Timeout:     timeout,
+		StartPeriod: startPeriod,
+		Retries:     request.Retries,
+	}, nil
+}
+
+// waitForHealthy polls ContainerInspect until name's health status becomes
+// healthy, returning an error on unhealthy or once the poll budget derived
+// from hc's interval/retries/startPeriod (or sane defaults) is exhausted.
+func waitForHealthy(name string, hc *HealthcheckRequest) error {
+	interval := defaultHealthPollInterval
+	retries := defaultHealthRetries
+	var startPeriod time.Duration
+
+	if hc != nil {
+		if parsed, err := parseDurationOrDefault(hc.Interval, interval); err == nil && parsed > 0 {
+			interval = parsed
+		}
+		if hc.Retries > 0 {
+			retries = hc.Retries
+		}
+		if parsed, err := parseDurationOrDefault(hc.StartPeriod, 0); err == nil {
+			startPeriod = parsed
+		}
+	}
+
+	if startPeriod > 0 {
+		time.Sleep(startPeriod)
+	}
+
+	deadline := time.Now().Add(interval * time.Duration(retries+1))
+	for {
+		info, err := docker.ContainerInspect(context.Background(), name)
+		if err != nil {
+			return err
+		}
+		if info.State == nil || info.State.Health == nil {
+			return errors.New("container has no healthcheck configured")
+		}
+
+		switch info.State.Health.Status {
+		case types.Healthy:
+			return nil
+		case types.Unhealthy:
+			return fmt.Errorf("container %q is unhealthy", name)
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("container %q did not become healthy in time", name)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// rollbackToSnapshot removes the (unhealthy) container named name and, if
+// previous is non-nil, recreates and starts it from that earlier snapshot.
+// It reports whether the previous container was restored.
+func rollbackToSnapshot(name string, previous *types.ContainerJSON) bool {
+	removeExistingContainer(name)
+
+	if previous == nil {
+		return false
+	}
+
+	restoredName := strings.TrimPrefix(previous.Name, "/")
+	created, err := docker.ContainerCreate(context.Background(), previous.Config, previous.HostConfig, nil, nil, restoredName)
+	if err != nil {
+		fmt.Println("[rollbackToSnapshot][ContainerCreate][ERROR] : " + err.Error())
+		return false
+	}
+	if err := docker.ContainerStart(context.Background(), created.ID, types.ContainerStartOptions{}); err != nil {
+		fmt.Println("[rollbackToSnapshot][ContainerStart][ERROR] : " + err.Error())
+		return false
+	}
+	return true
+}
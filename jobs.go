@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/google/uuid"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// DeploymentPhase is the lifecycle stage of a background deployment job.
+type DeploymentPhase string
+
+const (
+	PhaseQueued   DeploymentPhase = "queued"
+	PhasePulling  DeploymentPhase = "pulling"
+	PhaseCreating DeploymentPhase = "creating"
+	PhaseStarting DeploymentPhase = "starting"
+	PhaseRunning  DeploymentPhase = "running"
+	PhaseFailed   DeploymentPhase = "failed"
+)
+
+// deploymentWorkers is the number of goroutines draining the deployment
+// queue; deploymentQueueSize bounds how many jobs can be waiting at once.
+const (
+	deploymentWorkers   = 4
+	deploymentQueueSize = 64
+	deploymentTTL       = 15 * time.Minute
+)
+
+// deploymentEvent is one line of a deployment's /events SSE stream: either a
+// phase transition, a pull progress frame, or a terminal error.
+type deploymentEvent struct {
+	Phase   DeploymentPhase          `json:"phase,omitempty"`
+	Message *jsonmessage.JSONMessage `json:"message,omitempty"`
+	Error   string                   `json:"error,omitempty"`
+}
+
+// Deployment tracks one in-flight or finished deployment job: its phase, the
+// pull progress frames captured so far, and any subscribers waiting on
+// /events for new ones.
+type Deployment struct {
+	ID        string
+	CreatedAt time.Time
+
+	mu          sync.Mutex
+	phase       DeploymentPhase
+	err         string
+	rolledBack  bool
+	messages    []jsonmessage.JSONMessage
+	done        bool
+	subscribers map[chan deploymentEvent]struct{}
+}
+
+func newDeployment() *Deployment {
+	return &Deployment{
+		ID:          uuid.NewString(),
+		CreatedAt:   time.Now(),
+		phase:       PhaseQueued,
+		subscribers: map[chan deploymentEvent]struct{}{},
+	}
+}
+
+func (d *Deployment) publish(event deploymentEvent) {
+	for ch := range d.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// slow subscriber - drop the event rather than block the deploy
+		}
+	}
+}
+
+func (d *Deployment) setPhase(phase DeploymentPhase) {
+	d.mu.Lock()
+	d.phase = phase
+	done := phase == PhaseRunning || phase == PhaseFailed
+	d.done = done
+	d.publish(deploymentEvent{Phase: phase})
+	if done {
+		for ch := range d.subscribers {
+			close(ch)
+		}
+		d.subscribers = map[chan deploymentEvent]struct{}{}
+	}
+	d.mu.Unlock()
+}
+
+func (d *Deployment) fail(err error) {
+	d.mu.Lock()
+	d.err = err.Error()
+	d.mu.Unlock()
+	d.setPhase(PhaseFailed)
+}
+
+// setRolledBack records whether a failed healthcheck caused the previous
+// container to be restored, surfaced on GET /deployments/:id.
+func (d *Deployment) setRolledBack(rolledBack bool) {
+	d.mu.Lock()
+	d.rolledBack = rolledBack
+	d.mu.Unlock()
+}
+
+// recordProgress is passed to pullImage as its onProgress callback.
+func (d *Deployment) recordProgress(msg jsonmessage.JSONMessage) {
+	d.mu.Lock()
+	d.messages = append(d.messages, msg)
+	d.publish(deploymentEvent{Message: &msg})
+	d.mu.Unlock()
+}
+
+// snapshot returns the data GET /deployments/:id responds with.
+func (d *Deployment) snapshot() fiber.Map {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return fiber.Map{
+		"deploymentId": d.ID,
+		"phase":        d.phase,
+		"error":        d.err,
+		"rolledBack":   d.rolledBack,
+		"messages":     d.messages,
+	}
+}
+
+// subscribe registers a channel that receives every new event from this
+// point on, plus a replay of messages already captured. If the job is
+// already done, replay is everything and the channel is returned closed.
+func (d *Deployment) subscribe() (chan deploymentEvent, []jsonmessage.JSONMessage) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	replay := append([]jsonmessage.JSONMessage(nil), d.messages...)
+	ch := make(chan deploymentEvent, 32)
+	if d.done {
+		close(ch)
+		return ch, replay
+	}
+	d.subscribers[ch] = struct{}{}
+	return ch, replay
+}
+
+func (d *Deployment) unsubscribe(ch chan deploymentEvent) {
+	d.mu.Lock()
+	delete(d.subscribers, ch)
+	d.mu.Unlock()
+}
+
+// deploymentStore is an in-memory, TTL-expiring registry of deployment jobs
+// so a client can reconnect to /deployments/:id or its /events stream after
+// the initial POST / response.
+type deploymentStore struct {
+	mu   sync.Mutex
+	jobs map[string]*Deployment
+}
+
+var deployments = &deploymentStore{jobs: map[string]*Deployment{}}
+
+func (s *deploymentStore) create() *Deployment {
+	job := newDeployment()
+	s.mu.Lock()
+	s.jobs[job.ID] = job
+	s.mu.Unlock()
+	return job
+}
+
+func (s *deploymentStore) get(id string) (*Deployment, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	return job, ok
+}
+
+func (s *deploymentStore) reapExpired(ttl time.Duration) {
+	cutoff := time.Now().Add(-ttl)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, job := range s.jobs {
+		if job.CreatedAt.Before(cutoff) {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// runTTLReaper periodically purges deployments older than ttl so the store
+// doesn't grow without bound.
+func runTTLReaper(ttl time.Duration) {
+	ticker := time.NewTicker(ttl / 2)
+	for range ticker.C {
+		deployments.reapExpired(ttl)
+	}
+}
+
+var deploymentQueue chan func()
+
+// startDeploymentWorkers launches the bounded worker pool that drains
+// deploymentQueue; POST / enqueues onto it instead of running the deploy
+// inline so it can return 202 immediately.
+func startDeploymentWorkers(workers int, queueSize int) {
+	deploymentQueue = make(chan func(), queueSize)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for task := range deploymentQueue {
+				task()
+			}
+		}()
+	}
+}
+
+// enqueueDeployment offers task to the bounded queue without blocking,
+// reporting false if the queue is currently full.
+func enqueueDeployment(task func()) bool {
+	select {
+	case deploymentQueue <- task:
+		return true
+	default:
+		return false
+	}
+}
+
+func registerDeploymentRoutes(app *fiber.App) {
+	app.Get("/deployments/:id", func(c *fiber.Ctx) error {
+		job, ok := deployments.get(c.Params("id"))
+		if !ok {
+			return c.Status(404).JSON(&fiber.Map{"error": 1, "message": "deployment not found"})
+		}
+		return c.JSON(job.snapshot())
+	})
+
+	app.Get("/deployments/:id/events", func(c *fiber.Ctx) error {
+		job, ok := deployments.get(c.Params("id"))
+		if !ok {
+			return c.Status(404).JSON(&fiber.Map{"error": 1, "message": "deployment not found"})
+		}
+
+		ch, replay := job.subscribe()
+
+		c.Set(fiber.HeaderContentType, "text/event-stream")
+		c.Set(fiber.HeaderCacheControl, "no-cache")
+		c.Set(fiber.HeaderConnection, "keep-alive")
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			defer job.unsubscribe(ch)
+
+			writeEvent := func(event deploymentEvent) bool {
+				encoded, err := json.Marshal(event)
+				if err != nil {
+					return false
+				}
+				if _, err := w.Write([]byte("data: ")); err != nil {
+					return false
+				}
+				if _, err := w.Write(encoded); err != nil {
+					return false
+				}
+				if _, err := w.Write([]byte("\n\n")); err != nil {
+					return false
+				}
+				return w.Flush() == nil
+			}
+
+			for _, msg := range replay {
+				msg := msg
+				if !writeEvent(deploymentEvent{Message: &msg}) {
+					return
+				}
+			}
+			for event := range ch {
+				if !writeEvent(event) {
+					return
+				}
+			}
+		})
+		return nil
+	})
+}
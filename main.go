@@ -2,23 +2,13 @@ package main
 
 import (
 	"context"
-	"encoding/base64"
-	"encoding/json"
-	"errors"
 	"fmt"
-	"os"
-	"strconv"
-	"strings"
 
-	cliconfig "github.com/docker/cli/cli/config"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/mount"
-	"github.com/docker/docker/pkg/jsonmessage"
 	"github.com/docker/go-connections/nat"
 
-	"github.com/moby/term"
-
 	"github.com/docker/docker/client"
 	"github.com/gofiber/fiber/v2"
 )
@@ -34,112 +24,65 @@ type DockerVolumeBindingRequest struct {
 }
 
 type DockerRequest struct {
-	ContainerName  string                       `json:"name"`
-	CustomCommand  []string                     `json:"customCommand"`
-	Image          string                       `json:"image"`
-	PortBindings   []DockerPortBindingRequest   `json:"portBindings"`
-	VolumeBindings []DockerVolumeBindingRequest `json:"volumeBindings"`
-	Environment    []string                     `json:"environment"`
-	Memory         string                       `json:"memory"`
-	CPUShares      string                       `json:"cpuShares"`
+	ContainerName     string                       `json:"name"`
+	CustomCommand     []string                     `json:"customCommand"`
+	Image             string                       `json:"image"`
+	PortBindings      []DockerPortBindingRequest   `json:"portBindings"`
+	VolumeBindings    []DockerVolumeBindingRequest `json:"volumeBindings"`
+	Environment       []string                     `json:"environment"`
+	Memory            string                       `json:"memory"`
+	MemoryReservation string                       `json:"memoryReservation"`
+	MemorySwap        string                       `json:"memorySwap"`
+	CPUShares         string                       `json:"cpuShares"`
+	NanoCPUs          string                       `json:"nanoCpus"`
+	CPUQuota          int64                        `json:"cpuQuota"`
+	CPUPeriod         int64                        `json:"cpuPeriod"`
+	CpusetCpus        string                       `json:"cpusetCpus"`
+	PidsLimit         *int64                       `json:"pidsLimit"`
+	BlkioWeight       uint16                       `json:"blkioWeight"`
+	Ulimits           []UlimitRequest              `json:"ulimits"`
+	RegistryAuth      *RegistryAuthRequest         `json:"registryAuth"`
+	Healthcheck       *HealthcheckRequest          `json:"healthcheck"`
+	WaitForHealthy    bool                         `json:"waitForHealthy"`
 }
 
-var privateDockerRegistry = "d.puneet.cc"
 var docker *client.Client
 
-func convertToBytes(val string) (int64, error) {
-	units := map[string]int64{
-		"KB": 1024,
-		"MB": 1024 * 1024,
-		"GB": 1024 * 1024 * 1024,
-		"TB": 1024 * 1024 * 1024 * 1024,
-	}
-	val = strings.ToUpper(val)
-	unit := val[len(val)-2:]
-	if _, ok := units[unit]; !ok {
-		unit = val[len(val)-1:]
-		if _, ok := units[unit]; !ok {
-			return 0, fmt.Errorf("Invalid unit: %s", unit)
-		}
-		val = val[:len(val)-1]
-	}
-	numStr := val[:len(val)-len(unit)]
-	num, err := strconv.ParseInt(numStr, 10, 64)
+// snapshotExistingContainer stops and removes a container left over from a
+// previous deploy of the same name, if one exists, returning its spec so a
+// caller can recreate it later (e.g. if the new deploy's healthcheck
+// fails). It is not an error for the container to be absent.
+func snapshotExistingContainer(name string) *types.ContainerJSON {
+	info, err := docker.ContainerInspect(context.Background(), name)
 	if err != nil {
-		return 0, err
-	}
-	return num * units[unit], nil
-}
-
-func startContainer(request DockerRequest) error {
-	if !strings.HasPrefix(request.Image, privateDockerRegistry) {
-		return errors.New("only " + privateDockerRegistry + " images supported")
+		return nil
 	}
-
-	// Load docker registry config
-	cfg, err := cliconfig.Load("")
-	if err != nil {
-		return errors.New("config load failed")
+	if err := docker.ContainerStop(context.Background(), name, nil); err != nil {
+		fmt.Println("[snapshotExistingContainer][ContainerStop][ERROR] : " + err.Error())
 	}
-
-	conf, _ := cfg.GetAuthConfig(privateDockerRegistry)
-	registryAuthConfig := types.AuthConfig(conf)
-	jsonRegistryAuth, _ := json.Marshal(registryAuthConfig)
-	registryAuthBase64 := base64.StdEncoding.EncodeToString([]byte(jsonRegistryAuth))
-
-	reader, err := docker.ImagePull(context.Background(), request.Image, types.ImagePullOptions{
-		RegistryAuth: registryAuthBase64,
-	})
-
-	if err != nil {
-		return err
+	if err := docker.ContainerRemove(context.Background(), name, types.ContainerRemoveOptions{}); err != nil {
+		fmt.Println("[snapshotExistingContainer][ContainerRemove][ERROR] : " + err.Error())
 	}
-	defer reader.Close()
-
-	termFd, isTerm := term.GetFdInfo(os.Stderr)
-	jsonmessage.DisplayJSONMessagesStream(reader, os.Stderr, termFd, isTerm, nil)
+	return &info
+}
 
-	_, err = docker.ContainerInspect(context.Background(), request.ContainerName)
-	if err == nil {
-		// container exists - stop and remove
-		err = docker.ContainerStop(context.Background(), request.ContainerName, nil)
-		if err != nil {
-			fmt.Println("[startContainer][ContainerStop][ERROR] : " + err.Error())
-		}
-		err = docker.ContainerRemove(context.Background(), request.ContainerName, types.ContainerRemoveOptions{})
-		if err != nil {
-			fmt.Println("[startContainer][ContainerRemove][ERROR] : " + err.Error())
-		}
-	}
+func removeExistingContainer(name string) {
+	snapshotExistingContainer(name)
+}
 
+func buildHostConfig(request DockerRequest) (*container.HostConfig, error) {
 	hostConfig := &container.HostConfig{}
 
 	hostConfig.RestartPolicy = container.RestartPolicy{
 		Name: "always",
 	}
 
-	// handle memory and cpushare allocation
-	if request.Memory != "" || request.CPUShares != "" {
-		hostConfig.Resources = container.Resources{}
-	}
-
-	if request.Memory != "" {
-		memoryInBytes, err := convertToBytes(request.Memory)
-		if err != nil {
-			fmt.Println("[startContainer][Memory-Parsing][ERROR] : " + err.Error())
-			return err
-		}
-		hostConfig.Resources.Memory = memoryInBytes
-	}
-
-	if request.CPUShares != "" {
-		cpuShares, err := strconv.ParseInt(request.CPUShares, 10, 64)
-		if err != nil {
-			fmt.Println("[startContainer][CPUShares-Parsing][ERROR] : " + err.Error())
-			return err
-		}
-		hostConfig.Resources.CPUShares = cpuShares
+	resources, err := buildResources(request)
+	if err != nil {
+		fmt.Println("[buildHostConfig][Resources-Parsing][ERROR] : " + err.Error())
+		return nil, err
 	}
+	hostConfig.Resources = resources
 
 	// add: Volume Bindings
 	if len(request.VolumeBindings) > 0 {
@@ -163,7 +106,11 @@ func startContainer(request DockerRequest) error {
 		}
 	}
 
-	containerConfig := &container.Config{Image: request.Image}
+	return hostConfig, nil
+}
+
+func buildContainerConfig(request DockerRequest, labels map[string]string) (*container.Config, error) {
+	containerConfig := &container.Config{Image: request.Image, Labels: labels}
 
 	if len(request.CustomCommand) > 0 {
 		containerConfig.Cmd = request.CustomCommand
@@ -181,6 +128,30 @@ func startContainer(request DockerRequest) error {
 		}
 	}
 
+	healthConfig, err := buildHealthConfig(request.Healthcheck)
+	if err != nil {
+		return nil, err
+	}
+	containerConfig.Healthcheck = healthConfig
+
+	return containerConfig, nil
+}
+
+// startContainer runs a single-container deploy on behalf of job, reporting
+// phase transitions and pull progress onto it as it goes. hostConfig and
+// containerConfig are built up-front by the route handler so that
+// request-validation errors can be answered with 400 before the job is even
+// queued.
+func startContainer(job *Deployment, request DockerRequest, hostConfig *container.HostConfig, containerConfig *container.Config, registryAuthHeader string) error {
+	job.setPhase(PhasePulling)
+	if err := pullImage(request.Image, request.RegistryAuth, registryAuthHeader, job.recordProgress); err != nil {
+		return err
+	}
+
+	previous := snapshotExistingContainer(request.ContainerName)
+
+	job.setPhase(PhaseCreating)
+
 	c, err := docker.ContainerCreate(
 		context.Background(),
 		containerConfig,
@@ -192,8 +163,21 @@ func startContainer(request DockerRequest) error {
 		fmt.Println("[startContainer][ContainerCreate][ERROR] : " + err.Error())
 		return err
 	}
-	err = docker.ContainerStart(context.Background(), c.ID, types.ContainerStartOptions{})
-	return err
+
+	job.setPhase(PhaseStarting)
+	if err := docker.ContainerStart(context.Background(), c.ID, types.ContainerStartOptions{}); err != nil {
+		return err
+	}
+
+	if request.WaitForHealthy {
+		if err := waitForHealthy(request.ContainerName, request.Healthcheck); err != nil {
+			job.setRolledBack(rollbackToSnapshot(request.ContainerName, previous))
+			return err
+		}
+	}
+
+	job.setPhase(PhaseRunning)
+	return nil
 }
 
 func main() {
@@ -203,8 +187,15 @@ func main() {
 	}
 	docker = dockerCli
 
+	startDeploymentWorkers(deploymentWorkers, deploymentQueueSize)
+	go runTTLReaper(deploymentTTL)
+
 	app := fiber.New()
 
+	registerContainerRoutes(app)
+	registerStackRoutes(app)
+	registerDeploymentRoutes(app)
+
 	app.Post("/", func(c *fiber.Ctx) error {
 		// if c.Get("x-api-key") != os.Getenv("DOCKER_DEPLOY_SECRET") {
 		// 	return c.Status(500).JSON(&fiber.Map{"error": 1, "message": "Unauthorised Access"})
@@ -213,11 +204,28 @@ func main() {
 		if err := c.BodyParser(&request); err != nil {
 			return c.Status(500).JSON(&fiber.Map{"error": 1, "message": err.Error()})
 		}
-		err := startContainer(request)
+
+		hostConfig, err := buildHostConfig(request)
 		if err != nil {
-			return c.Status(500).JSON(&fiber.Map{"error": 1, "message": err.Error()})
+			return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"error": 1, "message": err.Error()})
+		}
+		containerConfig, err := buildContainerConfig(request, nil)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"error": 1, "message": err.Error()})
 		}
-		return c.JSON(&fiber.Map{"error": 0, "message": "Container Started"})
+
+		registryAuthHeader := c.Get(registryAuthHeaderName)
+		job := deployments.create()
+		queued := enqueueDeployment(func() {
+			if err := startContainer(job, request, hostConfig, containerConfig, registryAuthHeader); err != nil {
+				job.fail(err)
+			}
+		})
+		if !queued {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(&fiber.Map{"error": 1, "message": "deployment queue is full"})
+		}
+
+		return c.Status(fiber.StatusAccepted).JSON(&fiber.Map{"error": 0, "deploymentId": job.ID})
 	})
 
 	app.Listen(":4444")
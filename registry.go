@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	cliconfig "github.com/docker/cli/cli/config"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/jsonmessage"
+
+	"github.com/moby/term"
+)
+
+// registryAuthHeaderName is the header name the Docker API itself uses to carry
+// a pre-encoded base64(JSON AuthConfig) for a pull/push.
+const registryAuthHeaderName = "X-Registry-Auth"
+
+// allowedRegistryHostsEnv, when set, restricts pulls to a comma-separated
+// allowlist of registry hosts. Leaving it unset allows any registry.
+const allowedRegistryHostsEnv = "ALLOWED_REGISTRY_HOSTS"
+
+// RegistryAuthRequest carries per-request credentials for the registry an
+// image is pulled from. Auth, when set, is used as-is - it's the same
+// base64(JSON AuthConfig) the Docker API's X-Registry-Auth header accepts.
+// Otherwise Username/Password are used to build one for the image's
+// registry host.
+type RegistryAuthRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Auth     string `json:"auth"`
+}
+
+// registryHost returns the registry hostname an image will be pulled from,
+// defaulting to Docker Hub when the reference doesn't name one explicitly.
+func registryHost(image string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", err
+	}
+	return reference.Domain(named), nil
+}
+
+func allowedRegistryHosts() []string {
+	raw := os.Getenv(allowedRegistryHostsEnv)
+	if raw == "" {
+		return nil
+	}
+	hosts := strings.Split(raw, ",")
+	for i := range hosts {
+		hosts[i] = strings.TrimSpace(hosts[i])
+	}
+	return hosts
+}
+
+func isRegistryAllowed(host string) bool {
+	allowed := allowedRegistryHosts()
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, allowedHost := range allowed {
+		if allowedHost == host {
+			return true
+		}
+	}
+	return false
+}
+
+func encodeAuthConfig(auth types.AuthConfig) (string, error) {
+	encoded, err := json.Marshal(auth)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(encoded), nil
+}
+
+// resolveRegistryAuth returns the base64(JSON AuthConfig) ImagePull expects
+// as RegistryAuth, trying in order: a pre-encoded inline auth, inline
+// username/password, the caller-supplied X-Registry-Auth header, then the
+// local docker CLI config for the registry host.
+func resolveRegistryAuth(host string, inline *RegistryAuthRequest, header string) (string, error) {
+	if inline != nil && inline.Auth != "" {
+		return inline.Auth, nil
+	}
+	if inline != nil && inline.Username != "" {
+		return encodeAuthConfig(types.AuthConfig{
+			Username:      inline.Username,
+			Password:      inline.Password,
+			ServerAddress: host,
+		})
+	}
+	if header != "" {
+		return header, nil
+	}
+
+	cfg, err := cliconfig.Load("")
+	if err != nil {
+		return "", errors.New("config load failed")
+	}
+	conf, _ := cfg.GetAuthConfig(host)
+	return encodeAuthConfig(types.AuthConfig(conf))
+}
+
+// pullImage pulls image, resolving credentials as described on
+// resolveRegistryAuth. When onProgress is non-nil, every pull progress frame
+// is decoded and handed to it instead of being written to stderr - this is
+// how callers (e.g. the deployment job queue) capture and forward progress
+// to their own caller rather than losing it to the server's own logs.
+func pullImage(image string, inline *RegistryAuthRequest, header string, onProgress func(jsonmessage.JSONMessage)) error {
+	host, err := registryHost(image)
+	if err != nil {
+		return fmt.Errorf("invalid image reference %q: %w", image, err)
+	}
+	if !isRegistryAllowed(host) {
+		return fmt.Errorf("registry %q is not in the allowed registry list", host)
+	}
+
+	registryAuthBase64, err := resolveRegistryAuth(host, inline, header)
+	if err != nil {
+		return err
+	}
+
+	reader, err := docker.ImagePull(context.Background(), image, types.ImagePullOptions{
+		RegistryAuth: registryAuthBase64,
+	})
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if onProgress == nil {
+		termFd, isTerm := term.GetFdInfo(os.Stderr)
+		jsonmessage.DisplayJSONMessagesStream(reader, os.Stderr, termFd, isTerm, nil)
+		return nil
+	}
+
+	decoder := json.NewDecoder(reader)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := decoder.Decode(&msg); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		onProgress(msg)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	units "github.com/docker/go-units"
+)
+
+// UlimitRequest mirrors units.Ulimit, the type container.Resources.Ulimits
+// expects.
+type UlimitRequest struct {
+	Name string `json:"name"`
+	Soft int64  `json:"soft"`
+	Hard int64  `json:"hard"`
+}
+
+// validationError marks an error as caused by a malformed/inconsistent
+// request rather than a Docker engine failure, so route handlers can answer
+// with 400 instead of 500.
+type validationError struct{ err error }
+
+func (e *validationError) Error() string { return e.err.Error() }
+func (e *validationError) Unwrap() error { return e.err }
+
+func newValidationError(format string, args ...interface{}) error {
+	return &validationError{err: fmt.Errorf(format, args...)}
+}
+
+// isValidationError reports whether err (or something it wraps) is a
+// validationError.
+func isValidationError(err error) bool {
+	var v *validationError
+	return errors.As(err, &v)
+}
+
+// parseSize parses a Docker-style size string ("512m", "1.5g", "1Gi", bare
+// bytes, ...) using the same rules as the Docker CLI/API. go-units only
+// recognizes the bare unit ("g") or "b"/"ib"-suffixed forms ("gb", "gib"), so
+// a trailing bare "i" (as in the Kubernetes-style "Gi") is normalized to
+// "ib" before delegating to it.
+func parseSize(val string) (int64, error) {
+	normalized := val
+	if n := len(val); n >= 2 && strings.HasSuffix(strings.ToLower(val), "i") && strings.ContainsRune("kmgtpKMGTP", rune(val[n-2])) {
+		normalized = val + "b"
+	}
+
+	bytes, err := units.RAMInBytes(normalized)
+	if err != nil {
+		return 0, newValidationError("invalid size %q: %s", val, err.Error())
+	}
+	return bytes, nil
+}
+
+// parseNanoCPUs parses a CPU count string (e.g. "1.5") into the nano-CPU
+// units container.Resources.NanoCPUs expects.
+func parseNanoCPUs(val string) (int64, error) {
+	cpus, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return 0, newValidationError("invalid cpu count %q: %s", val, err.Error())
+	}
+	if cpus <= 0 {
+		return 0, newValidationError("cpu count must be positive: %q", val)
+	}
+	return int64(cpus * 1e9), nil
+}
+
+// buildResources translates the resource-related fields of request into a
+// container.Resources, validating each size/count field and the
+// combinations Docker itself rejects (e.g. swap below memory).
+func buildResources(request DockerRequest) (container.Resources, error) {
+	resources := container.Resources{
+		CPUQuota:    request.CPUQuota,
+		CPUPeriod:   request.CPUPeriod,
+		CpusetCpus:  request.CpusetCpus,
+		BlkioWeight: request.BlkioWeight,
+		PidsLimit:   request.PidsLimit,
+	}
+
+	if request.Memory != "" {
+		memory, err := parseSize(request.Memory)
+		if err != nil {
+			return resources, fmt.Errorf("memory: %w", err)
+		}
+		resources.Memory = memory
+	}
+
+	if request.CPUShares != "" {
+		cpuShares, err := strconv.ParseInt(request.CPUShares, 10, 64)
+		if err != nil {
+			return resources, newValidationError("cpuShares: invalid integer %q: %s", request.CPUShares, err.Error())
+		}
+		resources.CPUShares = cpuShares
+	}
+
+	if request.NanoCPUs != "" {
+		nanoCPUs, err := parseNanoCPUs(request.NanoCPUs)
+		if err != nil {
+			return resources, fmt.Errorf("nanoCpus: %w", err)
+		}
+		resources.NanoCPUs = nanoCPUs
+	}
+
+	if request.MemoryReservation != "" {
+		reservation, err := parseSize(request.MemoryReservation)
+		if err != nil {
+			return resources, fmt.Errorf("memoryReservation: %w", err)
+		}
+		resources.MemoryReservation = reservation
+	}
+
+	if request.MemorySwap != "" {
+		swap, err := parseSize(request.MemorySwap)
+		if err != nil {
+			return resources, fmt.Errorf("memorySwap: %w", err)
+		}
+		resources.MemorySwap = swap
+	}
+
+	if resources.MemorySwap > 0 && resources.Memory > 0 && resources.MemorySwap < resources.Memory {
+		return resources, newValidationError("memorySwap (%d bytes) must be >= memory (%d bytes)", resources.MemorySwap, resources.Memory)
+	}
+
+	for _, ulimit := range request.Ulimits {
+		if ulimit.Name == "" {
+			return resources, newValidationError("ulimits: name is required")
+		}
+		resources.Ulimits = append(resources.Ulimits, &units.Ulimit{
+			Name: ulimit.Name,
+			Soft: ulimit.Soft,
+			Hard: ulimit.Hard,
+		})
+	}
+
+	return resources, nil
+}
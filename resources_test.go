@@ -0,0 +1,63 @@
+package main
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	cases := []struct {
+		name    string
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{name: "bare bytes", input: "1024", want: 1024},
+		{name: "binary suffix", input: "512m", want: 512 * 1024 * 1024},
+		{name: "binary suffix explicit i", input: "1Gi", want: 1024 * 1024 * 1024},
+		{name: "fractional size", input: "1.5g", want: int64(1.5 * 1024 * 1024 * 1024)},
+		{name: "invalid", input: "not-a-size", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseSize(tc.input)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error for %q, got nil", tc.input)
+				}
+				if !isValidationError(err) {
+					t.Fatalf("expected a validationError, got %T: %v", err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("parseSize(%q) = %d, want %d", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBuildResourcesMemorySwap(t *testing.T) {
+	t.Run("swap below memory is rejected", func(t *testing.T) {
+		request := DockerRequest{Memory: "512m", MemorySwap: "256m"}
+		_, err := buildResources(request)
+		if err == nil {
+			t.Fatal("expected an error, got nil")
+		}
+		if !isValidationError(err) {
+			t.Fatalf("expected a validationError, got %T: %v", err, err)
+		}
+	})
+
+	t.Run("swap at or above memory is accepted", func(t *testing.T) {
+		request := DockerRequest{Memory: "512m", MemorySwap: "1g"}
+		resources, err := buildResources(request)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resources.MemorySwap <= resources.Memory {
+			t.Fatalf("expected swap %d > memory %d", resources.MemorySwap, resources.Memory)
+		}
+	})
+}
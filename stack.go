@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	stackLabel   = "deploy.stack"
+	serviceLabel = "deploy.service"
+)
+
+// ServiceRequest describes one container within a DeploymentRequest. The
+// embedded DockerRequest's ContainerName is the service's name - used as the
+// container's network alias and, combined with the stack name, its actual
+// container name.
+type ServiceRequest struct {
+	DockerRequest
+	DependsOn []string `json:"dependsOn"`
+}
+
+type DeploymentNetworkRequest struct {
+	Name string `json:"name"`
+}
+
+type DeploymentRequest struct {
+	Services []ServiceRequest          `json:"services"`
+	Network  *DeploymentNetworkRequest `json:"network"`
+}
+
+// orderServices topologically sorts services by DependsOn so that every
+// service starts after the services it depends on. It returns an error if a
+// dependency cycle is found or a service depends on one that doesn't exist.
+func orderServices(services []ServiceRequest) ([]ServiceRequest, error) {
+	byName := map[string]ServiceRequest{}
+	for _, service := range services {
+		byName[service.ContainerName] = service
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	ordered := make([]ServiceRequest, 0, len(services))
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return newValidationError("dependency cycle detected at service %q", name)
+		}
+
+		service, ok := byName[name]
+		if !ok {
+			return newValidationError("unknown service %q", name)
+		}
+
+		state[name] = visiting
+		for _, dependency := range service.DependsOn {
+			if _, ok := byName[dependency]; !ok {
+				return newValidationError("service %q depends on unknown service %q", name, dependency)
+			}
+			if err := visit(dependency); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		ordered = append(ordered, service)
+		return nil
+	}
+
+	for _, service := range services {
+		if err := visit(service.ContainerName); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+func stackNetworkName(stackName string, request DeploymentRequest) string {
+	if request.Network != nil && request.Network.Name != "" {
+		return request.Network.Name
+	}
+	return stackName
+}
+
+// ensureStackNetwork returns the ID of the bridge network for the stack,
+// creating one labelled with the stack name if it doesn't exist yet.
+func ensureStackNetwork(stackName string, networkName string) (string, error) {
+	existing, err := docker.NetworkInspect(context.Background(), networkName, types.NetworkInspectOptions{})
+	if err == nil {
+		return existing.ID, nil
+	}
+
+	created, err := docker.NetworkCreate(context.Background(), networkName, types.NetworkCreate{
+		Driver: "bridge",
+		Labels: map[string]string{stackLabel: stackName},
+	})
+	if err != nil {
+		return "", err
+	}
+	return created.ID, nil
+}
+
+func stackContainerName(stackName string, serviceName string) string {
+	return stackName + "-" + serviceName
+}
+
+// deployStack creates/reuses the stack's network and brings up every service
+// in dependency order, rolling back any container it already started if a
+// later service fails to deploy.
+func deployStack(stackName string, request DeploymentRequest, registryAuthHeader string) error {
+	if stackName == "" {
+		return newValidationError("stack name is required")
+	}
+	if len(request.Services) == 0 {
+		return newValidationError("at least one service is required")
+	}
+
+	orderedServices, err := orderServices(request.Services)
+	if err != nil {
+		return err
+	}
+
+	networkName := stackNetworkName(stackName, request)
+	if _, err := ensureStackNetwork(stackName, networkName); err != nil {
+		return err
+	}
+
+	started := make([]string, 0, len(orderedServices))
+	rollback := func() {
+		for i := len(started) - 1; i >= 0; i-- {
+			removeExistingContainer(started[i])
+		}
+	}
+
+	for _, service := range orderedServices {
+		if err := pullImage(service.Image, service.RegistryAuth, registryAuthHeader, nil); err != nil {
+			rollback()
+			return err
+		}
+
+		containerName := stackContainerName(stackName, service.ContainerName)
+		removeExistingContainer(containerName)
+
+		hostConfig, err := buildHostConfig(service.DockerRequest)
+		if err != nil {
+			rollback()
+			return err
+		}
+
+		labels := map[string]string{stackLabel: stackName, serviceLabel: service.ContainerName}
+		containerConfig, err := buildContainerConfig(service.DockerRequest, labels)
+		if err != nil {
+			rollback()
+			return err
+		}
+
+		networkingConfig := &network.NetworkingConfig{
+			EndpointsConfig: map[string]*network.EndpointSettings{
+				networkName: {Aliases: []string{service.ContainerName}},
+			},
+		}
+
+		created, err := docker.ContainerCreate(
+			context.Background(),
+			containerConfig,
+			hostConfig,
+			networkingConfig,
+			nil,
+			containerName,
+		)
+		if err != nil {
+			fmt.Println("[deployStack][ContainerCreate][ERROR] : " + err.Error())
+			rollback()
+			return err
+		}
+
+		if err := docker.ContainerStart(context.Background(), created.ID, types.ContainerStartOptions{}); err != nil {
+			rollback()
+			return err
+		}
+		started = append(started, containerName)
+	}
+
+	return nil
+}
+
+// teardownStack removes every container labelled with the stack and the
+// stack's network.
+func teardownStack(stackName string) error {
+	filterArgs := filters.NewArgs()
+	filterArgs.Add("label", stackLabel+"="+stackName)
+
+	containers, err := docker.ContainerList(context.Background(), types.ContainerListOptions{
+		All:     true,
+		Filters: filterArgs,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, c := range containers {
+		if err := docker.ContainerStop(context.Background(), c.ID, nil); err != nil {
+			fmt.Println("[teardownStack][ContainerStop][ERROR] : " + err.Error())
+		}
+		if err := docker.ContainerRemove(context.Background(), c.ID, types.ContainerRemoveOptions{}); err != nil {
+			fmt.Println("[teardownStack][ContainerRemove][ERROR] : " + err.Error())
+		}
+	}
+
+	networks, err := docker.NetworkList(context.Background(), types.NetworkListOptions{Filters: filterArgs})
+	if err != nil {
+		return err
+	}
+	for _, n := range networks {
+		if err := docker.NetworkRemove(context.Background(), n.ID); err != nil {
+			fmt.Println("[teardownStack][NetworkRemove][ERROR] : " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+func registerStackRoutes(app *fiber.App) {
+	app.Post("/stacks/:name", func(c *fiber.Ctx) error {
+		var request DeploymentRequest
+		if err := c.BodyParser(&request); err != nil {
+			return c.Status(500).JSON(&fiber.Map{"error": 1, "message": err.Error()})
+		}
+		if err := deployStack(c.Params("name"), request, c.Get(registryAuthHeaderName)); err != nil {
+			status := fiber.StatusInternalServerError
+			if isValidationError(err) {
+				status = fiber.StatusBadRequest
+			}
+			return c.Status(status).JSON(&fiber.Map{"error": 1, "message": err.Error()})
+		}
+		return c.JSON(&fiber.Map{"error": 0, "message": "Stack Deployed"})
+	})
+
+	app.Delete("/stacks/:name", func(c *fiber.Ctx) error {
+		if err := teardownStack(c.Params("name")); err != nil {
+			return c.Status(500).JSON(&fiber.Map{"error": 1, "message": err.Error()})
+		}
+		return c.JSON(&fiber.Map{"error": 0, "message": "Stack Removed"})
+	})
+}
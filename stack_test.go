@@ -0,0 +1,74 @@
+package main
+
+import "testing"
+
+func TestOrderServices(t *testing.T) {
+	svc := func(name string, dependsOn ...string) ServiceRequest {
+		return ServiceRequest{
+			DockerRequest: DockerRequest{ContainerName: name},
+			DependsOn:     dependsOn,
+		}
+	}
+
+	t.Run("diamond dependency orders dependencies before dependents", func(t *testing.T) {
+		services := []ServiceRequest{
+			svc("app", "cache", "db"),
+			svc("db", "base"),
+			svc("cache", "base"),
+			svc("base"),
+		}
+
+		ordered, err := orderServices(services)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		position := map[string]int{}
+		for i, service := range ordered {
+			position[service.ContainerName] = i
+		}
+		if position["base"] > position["db"] || position["base"] > position["cache"] {
+			t.Fatalf("base must come before its dependents, got order %v", names(ordered))
+		}
+		if position["db"] > position["app"] || position["cache"] > position["app"] {
+			t.Fatalf("app must come after its dependencies, got order %v", names(ordered))
+		}
+	})
+
+	t.Run("cycle is rejected", func(t *testing.T) {
+		services := []ServiceRequest{
+			svc("a", "b"),
+			svc("b", "a"),
+		}
+
+		_, err := orderServices(services)
+		if err == nil {
+			t.Fatal("expected a cycle error, got nil")
+		}
+		if !isValidationError(err) {
+			t.Fatalf("expected a validationError, got %T: %v", err, err)
+		}
+	})
+
+	t.Run("unknown dependency is rejected", func(t *testing.T) {
+		services := []ServiceRequest{
+			svc("app", "missing"),
+		}
+
+		_, err := orderServices(services)
+		if err == nil {
+			t.Fatal("expected an unknown-dependency error, got nil")
+		}
+		if !isValidationError(err) {
+			t.Fatalf("expected a validationError, got %T: %v", err, err)
+		}
+	})
+}
+
+func names(services []ServiceRequest) []string {
+	result := make([]string, len(services))
+	for i, service := range services {
+		result[i] = service.ContainerName
+	}
+	return result
+}